@@ -1,18 +1,166 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
+	"fmt"
 	"github.com/docker/infrakit/pkg/plugin/group"
 	"github.com/docker/infrakit/pkg/plugin/group/types"
+	"github.com/docker/infrakit/pkg/plugin/privilege"
 	"github.com/docker/infrakit/pkg/spi/flavor"
 	"github.com/docker/infrakit/pkg/spi/instance"
 	"strings"
+	"time"
 )
 
+// OnFailure is the policy flavorCombo applies when a child's Prepare call fails.
+type OnFailure string
+
+const (
+	// OnFailureAbort stops dispatching children that haven't started yet and returns the
+	// failure without waiting for children still in flight, which run to completion in the
+	// background. This is the default.
+	OnFailureAbort OnFailure = "abort"
+	// OnFailureContinue lets the other children keep running and reports the failure
+	// alongside whatever specs the other children produced.
+	OnFailureContinue OnFailure = "continue"
+	// OnFailureRollback invokes Rollback, in reverse prepare order, on every child that had
+	// already succeeded, then returns the failure.
+	OnFailureRollback OnFailure = "rollback"
+)
+
+// ComboFlavor configures one child flavor plugin within a combo, together with the policy for
+// handling a failure dispatching to it.
+type ComboFlavor struct {
+	types.FlavorPlugin
+
+	// OnFailure is applied if this child fails in Prepare. Defaults to OnFailureAbort.
+	OnFailure OnFailure
+}
+
 // Spec is the model of the plugin Properties.
 type Spec struct {
-	Flavors []types.FlavorPlugin
+	Flavors []ComboFlavor
+
+	// MaxParallel bounds how many children Prepare/Healthy/Drain dispatch to concurrently.
+	// 0 means unbounded.
+	MaxParallel int
+
+	// Timeout bounds how long the combo waits on its children in Prepare/Healthy/Drain.
+	// 0 means no deadline.
+	Timeout time.Duration
+
+	// PrivilegeGrant records the privileges the operator has explicitly authorized for this
+	// combo, as the union of what its Flavors declare.  Prepare refuses to run if the
+	// aggregated privileges declared by the configured Flavors are not a subset of this grant.
+	PrivilegeGrant privilege.Grant
+}
+
+// ChildError is one child flavor plugin's contribution to a MultiError.
+type ChildError struct {
+	Plugin string
+	Err    error
+	Spec   *instance.Spec // the spec this child produced before a later child failed, if any
+}
+
+// MultiError collects one error per failing child flavor, instead of concatenating error
+// strings, so a caller can tell which plugin failed and with what it had already produced.
+type MultiError struct {
+	Errors []ChildError
+}
+
+// Error satisfies the error interface.
+func (e *MultiError) Error() string {
+	parts := make([]string, len(e.Errors))
+	for i, c := range e.Errors {
+		parts[i] = fmt.Sprintf("%s: %v", c.Plugin, c.Err)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Rollbacker is implemented by a child flavor plugin that can undo the partial work it performed
+// in Prepare.  flavorCombo invokes it, in reverse prepare order, on every child that already
+// succeeded when a later child configured with OnFailureRollback subsequently fails.
+type Rollbacker interface {
+	Rollback(flavorProperties json.RawMessage, spec instance.Spec) error
+}
+
+type flavorResult struct {
+	index int
+	err   error
+}
+
+// forEachFlavor dispatches fn for every configured child concurrently, bounded by maxParallel (0
+// means unbounded), and returns each child's error indexed by its position in flavors.  If ctx is
+// cancelled or its deadline passes before every child reports back, forEachFlavor stops waiting
+// and fills in ctx.Err() for whichever children hadn't yet reported -- the underlying flavor SPI
+// calls aren't themselves cancellable, so those goroutines are left running in the background.
+func forEachFlavor(ctx context.Context, maxParallel int, flavors []ComboFlavor, fn func(ctx context.Context, index int, child ComboFlavor) error) []error {
+	errs := make([]error, len(flavors))
+	if len(flavors) == 0 {
+		return errs
+	}
+
+	if maxParallel <= 0 || maxParallel > len(flavors) {
+		maxParallel = len(flavors)
+	}
+	sem := make(chan struct{}, maxParallel)
+	results := make(chan flavorResult, len(flavors))
+
+dispatch:
+	for i, child := range flavors {
+		// maxParallel < len(flavors) means this can block waiting for a free slot; also
+		// watch ctx so a hung earlier child can't wedge the whole dispatch loop past the
+		// deadline -- every child still undispatched when ctx ends is reported as such.
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			for j := i; j < len(flavors); j++ {
+				results <- flavorResult{index: j, err: ctx.Err()}
+			}
+			break dispatch
+		}
+
+		go func(i int, child ComboFlavor) {
+			defer func() { <-sem }()
+
+			var err error
+			select {
+			case <-ctx.Done():
+				err = ctx.Err()
+			default:
+				err = fn(ctx, i, child)
+			}
+			results <- flavorResult{index: i, err: err}
+		}(i, child)
+	}
+
+	reported := make([]bool, len(flavors))
+	for remaining := len(flavors); remaining > 0; {
+		select {
+		case r := <-results:
+			errs[r.index] = r.err
+			reported[r.index] = true
+			remaining--
+		case <-ctx.Done():
+			for i := range errs {
+				if !reported[i] {
+					errs[i] = ctx.Err()
+				}
+			}
+			return errs
+		}
+	}
+
+	return errs
+}
+
+// deadline returns a context bounded by timeout, or a context with no deadline if timeout is 0.
+func deadline(timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), timeout)
 }
 
 // NewPlugin creates a Flavor Combo plugin that chains multiple flavors in a sequence.  Each flavor
@@ -29,58 +177,124 @@ func (f flavorCombo) Validate(flavorProperties json.RawMessage, allocation types
 	return json.Unmarshal(flavorProperties, &s)
 }
 
+// Privileges returns the union of privileges declared by every child flavor plugin configured in
+// this combo.  A chained combo that didn't surface this would let a child plugin's privileges
+// escalate silently, since the group controller only ever dispatches to the combo itself.
+func (f flavorCombo) Privileges(flavorProperties json.RawMessage) (privilege.Set, error) {
+	s := Spec{}
+	if err := json.Unmarshal(flavorProperties, &s); err != nil {
+		return nil, err
+	}
+
+	declared := privilege.Set{}
+	for _, pluginSpec := range s.Flavors {
+		plugin, err := f.flavorPlugins(pluginSpec.Plugin)
+		if err != nil {
+			return nil, err
+		}
+
+		d, is := plugin.(privilege.Declarer)
+		if !is {
+			continue
+		}
+
+		childPrivileges, err := d.Privileges(types.RawMessage(pluginSpec.Properties))
+		if err != nil {
+			return nil, err
+		}
+		declared = declared.Union(childPrivileges)
+	}
+
+	return declared, nil
+}
+
 func (f flavorCombo) Healthy(flavorProperties json.RawMessage, inst instance.Description) (flavor.Health, error) {
-	// The overall health of the flavor combination is taken as the 'lowest common demoninator' of the configured
-	// flavors.  Only flavor.Healthy is reported if all flavors report flavor.Healthy.  flavor.Unhealthy or
-	// flavor.UnknownHealth is returned as soon as any Flavor reports that value.
+	// The overall health of the flavor combination is taken as the 'lowest common denominator' of the configured
+	// flavors, dispatched concurrently.  flavor.Healthy is reported only if every flavor reports
+	// flavor.Healthy.  Otherwise flavor.Unhealthy takes priority over flavor.Unknown, since "a
+	// child is definitely unhealthy" is more actionable than "a child's health couldn't be
+	// determined".
 
 	s := Spec{}
 	if err := json.Unmarshal(flavorProperties, &s); err != nil {
 		return flavor.Unknown, err
 	}
 
-	for _, pluginSpec := range s.Flavors {
-		plugin, err := f.flavorPlugins(pluginSpec.Plugin)
+	ctx, cancel := deadline(s.Timeout)
+	defer cancel()
+
+	healths := make([]flavor.Health, len(s.Flavors))
+	errs := forEachFlavor(ctx, s.MaxParallel, s.Flavors, func(ctx context.Context, i int, child ComboFlavor) error {
+		plugin, err := f.flavorPlugins(child.Plugin)
 		if err != nil {
-			return flavor.Unknown, err
+			return err
 		}
+		health, err := plugin.Healthy(types.RawMessage(child.Properties), inst)
+		healths[i] = health
+		return err
+	})
 
-		health, err := plugin.Healthy(types.RawMessage(pluginSpec.Properties), inst)
-		if err != nil || health != flavor.Healthy {
-			return health, err
+	multi := &MultiError{}
+	anyUnhealthy, anyUnknown := false, false
+	for i, err := range errs {
+		if err != nil {
+			multi.Errors = append(multi.Errors, ChildError{Plugin: fmt.Sprintf("%v", s.Flavors[i].Plugin), Err: err})
+			anyUnknown = true
+			continue
 		}
+		switch healths[i] {
+		case flavor.Unhealthy:
+			anyUnhealthy = true
+		case flavor.Unknown:
+			anyUnknown = true
+		}
+	}
+
+	var reportErr error
+	if len(multi.Errors) > 0 {
+		reportErr = multi
 	}
 
-	return flavor.Healthy, nil
+	switch {
+	case anyUnhealthy:
+		return flavor.Unhealthy, reportErr
+	case anyUnknown:
+		return flavor.Unknown, reportErr
+	}
+	return flavor.Healthy, reportErr
 }
 
 func (f flavorCombo) Drain(flavorProperties json.RawMessage, inst instance.Description) error {
-	// Draining is attempted on all flavors regardless of errors encountered.  All errors encountered are combined
-	// and returned.
+	// Draining is attempted on every flavor concurrently, regardless of errors encountered.
+	// Errors are collected into a MultiError rather than concatenated into a single string, so a
+	// caller can tell which plugin(s) failed to drain.
 
 	s := Spec{}
 	if err := json.Unmarshal(flavorProperties, &s); err != nil {
 		return err
 	}
 
-	errs := []string{}
+	ctx, cancel := deadline(s.Timeout)
+	defer cancel()
 
-	for _, pluginSpec := range s.Flavors {
-		plugin, err := f.flavorPlugins(pluginSpec.Plugin)
+	errs := forEachFlavor(ctx, s.MaxParallel, s.Flavors, func(ctx context.Context, i int, child ComboFlavor) error {
+		plugin, err := f.flavorPlugins(child.Plugin)
 		if err != nil {
-			errs = append(errs, err.Error())
+			return err
 		}
+		return plugin.Drain(types.RawMessage(child.Properties), inst)
+	})
 
-		if err := plugin.Drain(types.RawMessage(pluginSpec.Properties), inst); err != nil {
-			errs = append(errs, err.Error())
+	multi := &MultiError{}
+	for i, err := range errs {
+		if err != nil {
+			multi.Errors = append(multi.Errors, ChildError{Plugin: fmt.Sprintf("%v", s.Flavors[i].Plugin), Err: err})
 		}
 	}
-
-	if len(errs) == 0 {
+	if len(multi.Errors) == 0 {
 		return nil
 	}
-
-	return errors.New(strings.Join(errs, ", "))
+	return multi
 }
 
 func cloneSpec(spec instance.Spec) instance.Spec {
@@ -144,22 +358,119 @@ func (f flavorCombo) Prepare(
 		return inst, err
 	}
 
-	specs := []instance.Spec{}
-	for _, pluginSpec := range combo.Flavors {
+	declared, err := f.Privileges(flavor)
+	if err != nil {
+		return inst, err
+	}
+	if missing := declared.Missing(combo.PrivilegeGrant.Privileges); len(missing) > 0 {
+		return inst, fmt.Errorf("privileges not granted: %v", missing)
+	}
+
+	ctx, cancel := deadline(combo.Timeout)
+	defer cancel()
+
+	// Children dispatch concurrently, so an OnFailureAbort child that fails can no longer stop
+	// later children from ever being invoked the way the old sequential combo did -- a child
+	// already inside its own Prepare call runs to completion regardless, since the flavor SPI
+	// itself isn't cancellable. Cancelling here only spares children that haven't started yet.
+	ctx, cancelOnAbort := context.WithCancel(ctx)
+	defer cancelOnAbort()
+
+	specs := make([]*instance.Spec, len(combo.Flavors))
+	errs := forEachFlavor(ctx, combo.MaxParallel, combo.Flavors, func(ctx context.Context, i int, child ComboFlavor) error {
 		// Copy the instance spec to prevent Flavor plugins from interfering with each other.
 		clone := cloneSpec(inst)
 
-		plugin, err := f.flavorPlugins(pluginSpec.Plugin)
+		plugin, err := f.flavorPlugins(child.Plugin)
 		if err != nil {
-			return inst, err
+			return err
 		}
 
-		flavorOutput, err := plugin.Prepare(types.RawMessage(pluginSpec.Properties), clone, allocation)
+		out, err := plugin.Prepare(types.RawMessage(child.Properties), clone, allocation)
 		if err != nil {
-			return inst, err
+			onFailure := child.OnFailure
+			if onFailure == "" {
+				onFailure = OnFailureAbort
+			}
+			if onFailure != OnFailureContinue {
+				cancelOnAbort()
+			}
+			return err
+		}
+		specs[i] = &out
+		return nil
+	})
+
+	multi := &MultiError{}
+	abort, rollback := false, false
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+
+		onFailure := combo.Flavors[i].OnFailure
+		if onFailure == "" {
+			onFailure = OnFailureAbort
+		}
+
+		multi.Errors = append(multi.Errors, ChildError{Plugin: fmt.Sprintf("%v", combo.Flavors[i].Plugin), Err: err})
+
+		switch onFailure {
+		case OnFailureRollback:
+			rollback = true
+		case OnFailureContinue:
+			// no-op: leave the other children's results in place
+		default:
+			abort = true
+		}
+	}
+
+	if rollback {
+		// Undo every already-succeeded child, in reverse prepare order.
+		for i := len(combo.Flavors) - 1; i >= 0; i-- {
+			if specs[i] == nil {
+				continue
+			}
+
+			plugin, err := f.flavorPlugins(combo.Flavors[i].Plugin)
+			if err != nil {
+				continue
+			}
+
+			back, is := plugin.(Rollbacker)
+			if !is {
+				continue
+			}
+
+			if err := back.Rollback(types.RawMessage(combo.Flavors[i].Properties), *specs[i]); err != nil {
+				multi.Errors = append(multi.Errors, ChildError{
+					Plugin: fmt.Sprintf("%v", combo.Flavors[i].Plugin),
+					Err:    fmt.Errorf("rollback: %v", err),
+					Spec:   specs[i],
+				})
+			}
 		}
-		specs = append(specs, flavorOutput)
 	}
 
-	return mergeSpecs(inst, specs)
+	if abort || rollback {
+		return inst, multi
+	}
+
+	succeeded := []instance.Spec{}
+	for _, s := range specs {
+		if s != nil {
+			succeeded = append(succeeded, *s)
+		}
+	}
+
+	merged, err := mergeSpecs(inst, succeeded)
+	if err != nil {
+		return inst, err
+	}
+	if len(multi.Errors) > 0 {
+		// Every failure here was OnFailureContinue: surface them alongside whatever the
+		// other children did produce, rather than dropping them silently.
+		return merged, multi
+	}
+	return merged, nil
 }
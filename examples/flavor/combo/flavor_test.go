@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/docker/infrakit/pkg/plugin"
+	"github.com/docker/infrakit/pkg/plugin/group/types"
+	"github.com/docker/infrakit/pkg/spi/flavor"
+	"github.com/docker/infrakit/pkg/spi/instance"
+)
+
+// fakeFlavor is a flavor.Plugin test double whose Prepare and Rollback results are scripted, and
+// which records the order in which Prepare and Rollback were invoked across every fakeFlavor in a
+// test so ordering can be asserted on.
+type fakeFlavor struct {
+	name        string
+	prepareErr  error
+	rollbackErr error
+	order       *[]string
+}
+
+func (f *fakeFlavor) Validate(flavorProperties json.RawMessage, allocation types.AllocationMethod) error {
+	return nil
+}
+
+func (f *fakeFlavor) Prepare(flavorProperties json.RawMessage, inst instance.Spec, allocation types.AllocationMethod) (instance.Spec, error) {
+	*f.order = append(*f.order, "prepare:"+f.name)
+	if f.prepareErr != nil {
+		return inst, f.prepareErr
+	}
+	inst.Tags[f.name] = "prepared"
+	return inst, nil
+}
+
+func (f *fakeFlavor) Healthy(flavorProperties json.RawMessage, inst instance.Description) (flavor.Health, error) {
+	return flavor.Healthy, nil
+}
+
+func (f *fakeFlavor) Drain(flavorProperties json.RawMessage, inst instance.Description) error {
+	return nil
+}
+
+func (f *fakeFlavor) Rollback(flavorProperties json.RawMessage, spec instance.Spec) error {
+	*f.order = append(*f.order, "rollback:"+f.name)
+	return f.rollbackErr
+}
+
+// slowFlavor blocks in Prepare until released, used to exercise the combo's timeout.
+type slowFlavor struct {
+	release chan struct{}
+}
+
+func (f *slowFlavor) Validate(flavorProperties json.RawMessage, allocation types.AllocationMethod) error {
+	return nil
+}
+
+func (f *slowFlavor) Prepare(flavorProperties json.RawMessage, inst instance.Spec, allocation types.AllocationMethod) (instance.Spec, error) {
+	<-f.release
+	return inst, nil
+}
+
+func (f *slowFlavor) Healthy(flavorProperties json.RawMessage, inst instance.Description) (flavor.Health, error) {
+	return flavor.Healthy, nil
+}
+
+func (f *slowFlavor) Drain(flavorProperties json.RawMessage, inst instance.Description) error {
+	return nil
+}
+
+func lookup(plugins map[plugin.Name]flavor.Plugin) func(plugin.Name) (flavor.Plugin, error) {
+	return func(name plugin.Name) (flavor.Plugin, error) {
+		p, has := plugins[name]
+		if !has {
+			return nil, errors.New("no-plugin:" + string(name))
+		}
+		return p, nil
+	}
+}
+
+func comboFlavor(name string, onFailure OnFailure) ComboFlavor {
+	return ComboFlavor{
+		FlavorPlugin: types.FlavorPlugin{Plugin: plugin.Name(name), Properties: types.RawMessage(`{}`)},
+		OnFailure:    onFailure,
+	}
+}
+
+func specJSON(t *testing.T, s Spec) json.RawMessage {
+	buf, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+func TestPrepareInterleavedFailuresContinue(t *testing.T) {
+	var order []string
+
+	ok1 := &fakeFlavor{name: "ok1", order: &order}
+	failing := &fakeFlavor{name: "failing", order: &order, prepareErr: errors.New("boom")}
+	ok2 := &fakeFlavor{name: "ok2", order: &order}
+
+	combo := flavorCombo{flavorPlugins: lookup(map[plugin.Name]flavor.Plugin{
+		"ok1":     ok1,
+		"failing": failing,
+		"ok2":     ok2,
+	})}
+
+	spec := Spec{Flavors: []ComboFlavor{
+		comboFlavor("ok1", OnFailureContinue),
+		comboFlavor("failing", OnFailureContinue),
+		comboFlavor("ok2", OnFailureContinue),
+	}}
+
+	out, err := combo.Prepare(specJSON(t, spec), instance.Spec{Tags: map[string]string{}}, types.AllocationMethod{})
+
+	multi, isMulti := err.(*MultiError)
+	if !isMulti || len(multi.Errors) != 1 {
+		t.Fatalf("expected a single-entry MultiError, got %v", err)
+	}
+	if multi.Errors[0].Plugin != "failing" {
+		t.Fatalf("expected the failing child to be named in the error, got %v", multi.Errors[0])
+	}
+	// The successful children's output must still be merged in under OnFailureContinue.
+	if out.Tags["ok1"] != "prepared" || out.Tags["ok2"] != "prepared" {
+		t.Fatalf("expected ok1 and ok2 tags to be merged, got %v", out.Tags)
+	}
+	if _, has := out.Tags["failing"]; has {
+		t.Fatalf("the failing child shouldn't have produced a tag")
+	}
+}
+
+func TestPrepareAbortStopsShortOfMerging(t *testing.T) {
+	var order []string
+
+	ok := &fakeFlavor{name: "ok", order: &order}
+	failing := &fakeFlavor{name: "failing", order: &order, prepareErr: errors.New("boom")}
+
+	combo := flavorCombo{flavorPlugins: lookup(map[plugin.Name]flavor.Plugin{
+		"ok":      ok,
+		"failing": failing,
+	})}
+
+	spec := Spec{Flavors: []ComboFlavor{
+		comboFlavor("ok", OnFailureAbort),
+		comboFlavor("failing", OnFailureAbort),
+	}}
+
+	out, err := combo.Prepare(specJSON(t, spec), instance.Spec{Tags: map[string]string{}}, types.AllocationMethod{})
+
+	if _, isMulti := err.(*MultiError); !isMulti {
+		t.Fatalf("expected a MultiError, got %v", err)
+	}
+	if len(out.Tags) != 0 {
+		t.Fatalf("abort must not merge any partial output, got %v", out.Tags)
+	}
+}
+
+func TestPrepareRollbackInvokedInReverseOrder(t *testing.T) {
+	var order []string
+
+	first := &fakeFlavor{name: "first", order: &order}
+	second := &fakeFlavor{name: "second", order: &order}
+	failing := &fakeFlavor{name: "failing", order: &order, prepareErr: errors.New("boom")}
+
+	combo := flavorCombo{flavorPlugins: lookup(map[plugin.Name]flavor.Plugin{
+		"first":   first,
+		"second":  second,
+		"failing": failing,
+	})}
+
+	spec := Spec{
+		MaxParallel: 1, // force deterministic prepare order for this assertion
+		Flavors: []ComboFlavor{
+			comboFlavor("first", OnFailureRollback),
+			comboFlavor("second", OnFailureRollback),
+			comboFlavor("failing", OnFailureRollback),
+		},
+	}
+
+	_, err := combo.Prepare(specJSON(t, spec), instance.Spec{Tags: map[string]string{}}, types.AllocationMethod{})
+
+	if _, isMulti := err.(*MultiError); !isMulti {
+		t.Fatalf("expected a MultiError, got %v", err)
+	}
+
+	expected := []string{"prepare:first", "prepare:second", "prepare:failing", "rollback:second", "rollback:first"}
+	if len(order) != len(expected) {
+		t.Fatalf("expected order %v, got %v", expected, order)
+	}
+	for i := range expected {
+		if order[i] != expected[i] {
+			t.Fatalf("expected order %v, got %v", expected, order)
+		}
+	}
+}
+
+func TestPrepareBoundedParallelismDoesNotDeadlockOnHungChild(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	var order []string
+	second := &fakeFlavor{name: "second", order: &order}
+
+	combo := flavorCombo{flavorPlugins: lookup(map[plugin.Name]flavor.Plugin{
+		"slow":   &slowFlavor{release: release},
+		"second": second,
+	})}
+
+	spec := Spec{
+		MaxParallel: 1, // only one slot, so "second" can't even be dispatched while "slow" hangs
+		Timeout:     10 * time.Millisecond,
+		Flavors: []ComboFlavor{
+			comboFlavor("slow", OnFailureAbort),
+			comboFlavor("second", OnFailureAbort),
+		},
+	}
+
+	done := make(chan struct{})
+	var err error
+	go func() {
+		_, err = combo.Prepare(specJSON(t, spec), instance.Spec{Tags: map[string]string{}}, types.AllocationMethod{})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected Prepare to return once its deadline passed, not block waiting for a free dispatch slot")
+	}
+
+	multi, isMulti := err.(*MultiError)
+	if !isMulti || len(multi.Errors) != 2 {
+		t.Fatalf("expected both children to report the deadline, got %v", err)
+	}
+	for _, e := range multi.Errors {
+		if e.Err != context.DeadlineExceeded {
+			t.Fatalf("expected a deadline-exceeded error for %s, got %v", e.Plugin, e.Err)
+		}
+	}
+}
+
+func TestPrepareTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	combo := flavorCombo{flavorPlugins: lookup(map[plugin.Name]flavor.Plugin{
+		"slow": &slowFlavor{release: release},
+	})}
+
+	spec := Spec{
+		Timeout: 10 * time.Millisecond,
+		Flavors: []ComboFlavor{comboFlavor("slow", OnFailureAbort)},
+	}
+
+	_, err := combo.Prepare(specJSON(t, spec), instance.Spec{Tags: map[string]string{}}, types.AllocationMethod{})
+
+	multi, isMulti := err.(*MultiError)
+	if !isMulti || len(multi.Errors) != 1 {
+		t.Fatalf("expected a single-entry MultiError from the timeout, got %v", err)
+	}
+	if multi.Errors[0].Err != context.DeadlineExceeded {
+		t.Fatalf("expected a deadline-exceeded error, got %v", multi.Errors[0].Err)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"os/exec"
 	"path"
 
+	"github.com/docker/infrakit/pkg/plugin/privilege"
 	"github.com/docker/infrakit/pkg/spi/instance"
 	"github.com/docker/infrakit/pkg/template"
 )
@@ -29,6 +30,17 @@ func (v vagrantPlugin) Validate(req json.RawMessage) error {
 	return nil
 }
 
+// Privileges declares the host capabilities this plugin needs: it shells out to the vagrant
+// binary (which in turn shells out to ssh) and reads and writes Vagrantfiles and their state
+// under VagrantfilesDir.
+func (v vagrantPlugin) Privileges(_ json.RawMessage) (privilege.Set, error) {
+	return privilege.Set{
+		{Kind: privilege.Exec, Value: "vagrant"},
+		{Kind: privilege.Exec, Value: "ssh"},
+		{Kind: privilege.Mount, Value: v.VagrantfilesDir},
+	}, nil
+}
+
 func inheritedEnvCommand(cmdAndArgs []string, extraEnv ...string) (string, error) {
 	cmd := exec.Command(cmdAndArgs[0], cmdAndArgs[1:]...)
 	cmd.Env = append(os.Environ(), extraEnv...)
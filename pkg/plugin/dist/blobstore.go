@@ -0,0 +1,126 @@
+package dist
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// hexDigest matches the hex portion of a "sha256:<hex>" Digest: 64 lowercase hex characters, no
+// more and no less, so a crafted digest can never smuggle a path traversal into blobPath.
+var hexDigest = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// refNamePattern matches a single valid refs/ path segment.
+var refNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// BlobStore is a local, content-addressable store for plugin manifests, config blobs and
+// executable layers, rooted at a directory such as ~/.infrakit/plugins.  Blobs live at
+// blobs/sha256/<hex digest>; human-friendly names (e.g. "vanilla") are plain-text pointers to a
+// digest under refs/<name>.
+type BlobStore struct {
+	Root string
+}
+
+// NewBlobStore returns a BlobStore rooted at dir, creating its blobs and refs directories if they
+// don't already exist.
+func NewBlobStore(dir string) (*BlobStore, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "blobs", "sha256"), 0700); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "refs"), 0700); err != nil {
+		return nil, err
+	}
+	return &BlobStore{Root: dir}, nil
+}
+
+func (s *BlobStore) blobPath(digest Digest) (string, error) {
+	hex := strings.TrimPrefix(string(digest), "sha256:")
+	if hex == string(digest) || !hexDigest.MatchString(hex) {
+		return "", fmt.Errorf("unsupported digest: %s", digest)
+	}
+	return filepath.Join(s.Root, "blobs", "sha256", hex), nil
+}
+
+// Put stores b in the blobstore, if it isn't already present, and returns its digest.
+func (s *BlobStore) Put(b []byte) (Digest, error) {
+	digest := SumBytes(b)
+	path, err := s.blobPath(digest)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return digest, nil
+	}
+	return digest, ioutil.WriteFile(path, b, 0400)
+}
+
+// Get retrieves the blob stored under digest, re-verifying its content against the digest.
+func (s *BlobStore) Get(digest Digest) ([]byte, error) {
+	path, err := s.blobPath(digest)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if sum := SumBytes(b); sum != digest {
+		return nil, fmt.Errorf("corrupt blob: expected %s, got %s", digest, sum)
+	}
+	return b, nil
+}
+
+// refPath normalizes name down to the last "/"-separated segment -- so a name defaulted to a full
+// repository (e.g. "registry.example.com/org/vanilla") collapses to "vanilla" instead of trying to
+// create subdirectories under refs/ -- and rejects anything that could still escape Root, including
+// any name with a "." or ".." path segment.
+func (s *BlobStore) refPath(name string) (string, error) {
+	for _, segment := range strings.Split(name, "/") {
+		if segment == "." || segment == ".." {
+			return "", fmt.Errorf("invalid ref name: %q", name)
+		}
+	}
+	normalized := name
+	if i := strings.LastIndex(normalized, "/"); i >= 0 {
+		normalized = normalized[i+1:]
+	}
+	if normalized == "" || normalized == "." || normalized == ".." || !refNamePattern.MatchString(normalized) {
+		return "", fmt.Errorf("invalid ref name: %q", name)
+	}
+	return filepath.Join(s.Root, "refs", normalized), nil
+}
+
+// Tag records that name currently resolves to digest.
+func (s *BlobStore) Tag(name string, digest Digest) error {
+	path, err := s.refPath(name)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, []byte(digest), 0600)
+}
+
+// Resolve returns the digest that name currently points to.
+func (s *BlobStore) Resolve(name string) (Digest, error) {
+	path, err := s.refPath(name)
+	if err != nil {
+		return "", err
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return Digest(b), nil
+}
+
+// Remove deletes the ref for name.  The underlying blobs are left in place since they may be
+// shared with other refs; garbage collecting unreferenced blobs is a separate concern.
+func (s *BlobStore) Remove(name string) error {
+	path, err := s.refPath(name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
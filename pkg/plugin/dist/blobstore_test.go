@@ -0,0 +1,184 @@
+package dist
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBlobStorePutGetRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dist-blobstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewBlobStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest, err := store.Put([]byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := store.Get(digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "hello" {
+		t.Fatalf("expected %q, got %q", "hello", string(b))
+	}
+
+	if err := store.Tag("greeting", digest); err != nil {
+		t.Fatal(err)
+	}
+	resolved, err := store.Resolve("greeting")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved != digest {
+		t.Fatalf("expected resolved digest %s, got %s", digest, resolved)
+	}
+
+	if err := store.Remove("greeting"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Resolve("greeting"); err == nil {
+		t.Fatal("expected Resolve to fail after Remove")
+	}
+}
+
+func TestBlobStoreRejectsPathTraversalDigests(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dist-blobstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewBlobStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, digest := range []Digest{
+		"sha256:../../../../etc/passwd",
+		"sha256:",
+		"sha256:not-hex",
+		"md5:deadbeef",
+	} {
+		if _, err := store.Get(digest); err == nil {
+			t.Fatalf("expected Get(%s) to be rejected", digest)
+		}
+	}
+}
+
+func TestBlobStoreTagNormalizesRepositoryDefaultedNames(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dist-blobstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewBlobStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest, err := store.Put([]byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Install defaults name to the full repository when no --alias is given.
+	if err := store.Tag("registry.example.com/org/vanilla", digest); err != nil {
+		t.Fatal(err)
+	}
+
+	resolved, err := store.Resolve("registry.example.com/org/vanilla")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved != digest {
+		t.Fatalf("expected resolved digest %s, got %s", digest, resolved)
+	}
+
+	// Normalized down to its last path segment, so it also resolves under the short name.
+	if _, err := store.Resolve("vanilla"); err != nil {
+		t.Fatalf("expected the normalized name to also resolve: %v", err)
+	}
+}
+
+func TestBlobStoreRejectsPathTraversalNames(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dist-blobstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewBlobStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest, err := store.Put([]byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outside := filepath.Join(dir, "..", "..", "..", "..", "etc", "passwd")
+	before, beforeErr := os.Stat(outside)
+
+	for _, name := range []string{"../../../../etc/passwd", "..", "."} {
+		if err := store.Tag(name, digest); err == nil {
+			t.Fatalf("expected Tag(%q) to be rejected", name)
+		}
+		if _, err := store.Resolve(name); err == nil {
+			t.Fatalf("expected Resolve(%q) to be rejected", name)
+		}
+		if err := store.Remove(name); err == nil {
+			t.Fatalf("expected Remove(%q) to be rejected", name)
+		}
+	}
+
+	after, afterErr := os.Stat(outside)
+	if (beforeErr == nil) != (afterErr == nil) || (afterErr == nil && !after.ModTime().Equal(before.ModTime())) {
+		t.Fatal("a traversal name must never reach the filesystem outside Root")
+	}
+}
+
+func TestBlobStoreGetDetectsCorruption(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dist-blobstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewBlobStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest, err := store.Put([]byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := store.blobPath(digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Put stores blobs read-only; make this one writable so it can be tampered with.
+	if err := os.Chmod(path, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte("tampered"), 0400); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.Get(digest); err == nil {
+		t.Fatal("expected Get to detect a tampered blob")
+	}
+}
@@ -0,0 +1,148 @@
+package dist
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Ref is a parsed `infrakit plugin install` reference, e.g.
+// "registry.example.com/vanilla@sha256:abcd..." or "registry.example.com/vanilla:latest".
+type Ref struct {
+	Repository string
+	Tag        string // set when the reference names a tag rather than a digest
+	Digest     Digest // set when the reference names a digest rather than a tag
+}
+
+// ParseRef parses a registry reference of the form "repository[:tag|@digest]".  A bare
+// repository with neither tag nor digest defaults to the "latest" tag.
+func ParseRef(ref string) (Ref, error) {
+	if i := strings.Index(ref, "@"); i >= 0 {
+		return Ref{Repository: ref[:i], Digest: Digest(ref[i+1:])}, nil
+	}
+	if i := strings.LastIndex(ref, ":"); i >= 0 && !strings.Contains(ref[i:], "/") {
+		return Ref{Repository: ref[:i], Tag: ref[i+1:]}, nil
+	}
+	return Ref{Repository: ref, Tag: "latest"}, nil
+}
+
+// Install resolves ref against registry, pulls and verifies its manifest, config, and layers end
+// to end, and stores them in store under name, or under ref.Repository if name is empty.  The store
+// normalizes a ref name to its last path segment, so without an explicit name two repositories with
+// the same short name share one local ref.  It returns the digest the local name now points to.
+func Install(registry Registry, store *BlobStore, ref Ref, name string) (Digest, error) {
+	if name == "" {
+		name = ref.Repository
+	}
+
+	digest := ref.Digest
+	if digest == "" {
+		resolved, err := registry.Resolve(ref.Repository, ref.Tag)
+		if err != nil {
+			return "", fmt.Errorf("resolve %s:%s: %v", ref.Repository, ref.Tag, err)
+		}
+		digest = resolved
+	}
+
+	manifestBlob, err := registry.Pull(ref.Repository, digest)
+	if err != nil {
+		return "", fmt.Errorf("pull manifest %s@%s: %v", ref.Repository, digest, err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(manifestBlob, &manifest); err != nil {
+		return "", fmt.Errorf("decode manifest %s@%s: %v", ref.Repository, digest, err)
+	}
+
+	if _, err := store.Put(manifestBlob); err != nil {
+		return "", fmt.Errorf("store manifest %s@%s: %v", ref.Repository, digest, err)
+	}
+
+	if manifest.ConfigDigest != "" {
+		config, err := registry.Pull(ref.Repository, manifest.ConfigDigest)
+		if err != nil {
+			return "", fmt.Errorf("pull config %s@%s: %v", ref.Repository, manifest.ConfigDigest, err)
+		}
+		if _, err := store.Put(config); err != nil {
+			return "", fmt.Errorf("store config %s@%s: %v", ref.Repository, manifest.ConfigDigest, err)
+		}
+	}
+
+	for _, layer := range manifest.Layers {
+		blob, err := registry.Pull(ref.Repository, layer)
+		if err != nil {
+			return "", fmt.Errorf("pull layer %s@%s: %v", ref.Repository, layer, err)
+		}
+		if _, err := store.Put(blob); err != nil {
+			return "", fmt.Errorf("store layer %s@%s: %v", ref.Repository, layer, err)
+		}
+	}
+
+	if err := store.Tag(name, digest); err != nil {
+		return "", fmt.Errorf("tag %s as %s: %v", digest, name, err)
+	}
+
+	return digest, nil
+}
+
+// Push publishes the manifest (and its config and layers) tagged name in store to registry under
+// repository, and returns the manifest's digest.
+func Push(registry Registry, store *BlobStore, name, repository string) (Digest, error) {
+	digest, err := store.Resolve(name)
+	if err != nil {
+		return "", fmt.Errorf("resolve local ref %s: %v", name, err)
+	}
+
+	manifestBlob, err := store.Get(digest)
+	if err != nil {
+		return "", fmt.Errorf("load manifest %s: %v", digest, err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(manifestBlob, &manifest); err != nil {
+		return "", fmt.Errorf("decode manifest %s: %v", digest, err)
+	}
+
+	if manifest.ConfigDigest != "" {
+		config, err := store.Get(manifest.ConfigDigest)
+		if err != nil {
+			return "", fmt.Errorf("load config %s: %v", manifest.ConfigDigest, err)
+		}
+		if _, err := registry.Push(repository, config); err != nil {
+			return "", fmt.Errorf("push config %s: %v", manifest.ConfigDigest, err)
+		}
+	}
+
+	for _, layer := range manifest.Layers {
+		blob, err := store.Get(layer)
+		if err != nil {
+			return "", fmt.Errorf("load layer %s: %v", layer, err)
+		}
+		if _, err := registry.Push(repository, blob); err != nil {
+			return "", fmt.Errorf("push layer %s: %v", layer, err)
+		}
+	}
+
+	return registry.Push(repository, manifestBlob)
+}
+
+// Inspect returns the manifest that name currently resolves to in store.
+func Inspect(store *BlobStore, name string) (Manifest, error) {
+	digest, err := store.Resolve(name)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("resolve local ref %s: %v", name, err)
+	}
+
+	blob, err := store.Get(digest)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("load manifest %s: %v", digest, err)
+	}
+
+	var manifest Manifest
+	return manifest, json.Unmarshal(blob, &manifest)
+}
+
+// Remove deletes the local ref name.
+func Remove(store *BlobStore, name string) error {
+	return store.Remove(name)
+}
@@ -0,0 +1,48 @@
+package dist
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/docker/infrakit/pkg/spi"
+)
+
+// SchemaVersion identifies the manifest schema produced by this package.
+const SchemaVersion = "infrakit.plugin/v1"
+
+// Digest is a content hash of the form "sha256:<hex>" identifying a manifest, config blob, or
+// executable layer in the blobstore.
+type Digest string
+
+// Manifest describes a single plugin build in a content-addressable way: the SPI it implements,
+// how to run it, and the digest of the configuration it was built against.  Its own digest (see
+// Sum) is the identity `infrakit plugin install <ref>` resolves and that a group spec can pin a
+// flavor or instance plugin to, e.g. `vanilla@sha256:...`.
+type Manifest struct {
+	SchemaVersion string            `json:"schema_version"`
+	Interface     spi.InterfaceSpec `json:"interface"`
+	Entrypoint    string            `json:"entrypoint"`
+	Env           []string          `json:"env,omitempty"`
+	Mounts        []string          `json:"mounts,omitempty"`
+	ConfigDigest  Digest            `json:"config_digest"`
+	Layers        []Digest          `json:"layers,omitempty"`
+}
+
+// Sum computes the content digest of a manifest: sha256 over its canonical JSON encoding.  Two
+// manifests that marshal identically always resolve to the same digest, regardless of in-memory
+// field order.
+func Sum(m Manifest) (Digest, error) {
+	buf, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	return SumBytes(buf), nil
+}
+
+// SumBytes computes the content digest of an arbitrary blob, such as a config or a layer.
+func SumBytes(b []byte) Digest {
+	sum := sha256.Sum256(b)
+	return Digest(fmt.Sprintf("sha256:%s", hex.EncodeToString(sum[:])))
+}
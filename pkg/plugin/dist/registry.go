@@ -0,0 +1,112 @@
+package dist
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// Registry is the minimal client surface this package needs from a registry: push and pull of
+// content-addressable blobs by digest, plus tag resolution.  infrakit reuses the OCI
+// distribution-spec protocol that Docker images are distributed over, so any compliant registry
+// works without a dedicated plugin registry server.
+type Registry interface {
+	// Pull fetches the blob referenced by digest from repository.
+	Pull(repository string, digest Digest) ([]byte, error)
+	// Push uploads b to repository and returns its digest.
+	Push(repository string, b []byte) (Digest, error)
+	// Resolve looks up the digest that tag currently points to in repository.
+	Resolve(repository, tag string) (Digest, error)
+}
+
+// HTTPRegistry is a Registry backed directly by the OCI distribution-spec HTTP API.
+type HTTPRegistry struct {
+	Client *http.Client
+	Host   string // e.g. "registry-1.docker.io"
+}
+
+// NewHTTPRegistry returns a Registry talking to host over HTTPS.
+func NewHTTPRegistry(host string) *HTTPRegistry {
+	return &HTTPRegistry{Client: http.DefaultClient, Host: host}
+}
+
+func (r *HTTPRegistry) blobURL(repository string, digest Digest) string {
+	return fmt.Sprintf("https://%s/v2/%s/blobs/%s", r.Host, repository, digest)
+}
+
+// Pull fetches the blob referenced by digest and verifies it against the digest before returning.
+func (r *HTTPRegistry) Pull(repository string, digest Digest) ([]byte, error) {
+	resp, err := r.Client.Get(r.blobURL(repository, digest))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("pull %s@%s: %s", repository, digest, resp.Status)
+	}
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if sum := SumBytes(b); sum != digest {
+		return nil, fmt.Errorf("digest mismatch for %s: expected %s, got %s", repository, digest, sum)
+	}
+	return b, nil
+}
+
+// Push uploads b to repository via the distribution-spec monolithic blob upload and returns its
+// digest.
+func (r *HTTPRegistry) Push(repository string, b []byte) (Digest, error) {
+	digest := SumBytes(b)
+
+	initURL := fmt.Sprintf("https://%s/v2/%s/blobs/uploads/", r.Host, repository)
+	initResp, err := r.Client.Post(initURL, "application/octet-stream", nil)
+	if err != nil {
+		return "", err
+	}
+	defer initResp.Body.Close()
+
+	location := initResp.Header.Get("Location")
+	if location == "" {
+		return "", fmt.Errorf("push %s: registry did not return an upload location", repository)
+	}
+
+	uploadURL := fmt.Sprintf("%s&digest=%s", location, digest)
+	req, err := http.NewRequest(http.MethodPut, uploadURL, bytes.NewReader(b))
+	if err != nil {
+		return "", err
+	}
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return "", fmt.Errorf("push %s@%s: %s", repository, digest, resp.Status)
+	}
+	return digest, nil
+}
+
+// Resolve looks up the digest a tag currently points to by reading the tag's manifest.
+func (r *HTTPRegistry) Resolve(repository, tag string) (Digest, error) {
+	url := fmt.Sprintf("https://%s/v2/%s/manifests/%s", r.Host, repository, tag)
+	resp, err := r.Client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("resolve %s:%s: %s", repository, tag, resp.Status)
+	}
+
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		return "", fmt.Errorf("resolve %s:%s: registry did not return a content digest", repository, tag)
+	}
+	return Digest(digest), nil
+}
@@ -0,0 +1,53 @@
+package dist
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// httpOnly rewrites a request's scheme from https to http so a *HTTPRegistry, which always builds
+// https:// URLs, can be pointed at an httptest.Server.
+type httpOnly struct{}
+
+func (httpOnly) RoundTrip(req *http.Request) (*http.Response, error) {
+	req.URL.Scheme = "http"
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestHTTPRegistryPushSendsBlobBody(t *testing.T) {
+	var uploaded []byte
+
+	var serverURL string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case http.MethodPost:
+			w.Header().Set("Location", serverURL+"/v2/repo/blobs/uploads/1?")
+			w.WriteHeader(http.StatusAccepted)
+		case http.MethodPut:
+			b, err := ioutil.ReadAll(req.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			uploaded = b
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer server.Close()
+	serverURL = server.URL
+
+	registry := &HTTPRegistry{
+		Client: &http.Client{Transport: httpOnly{}},
+		Host:   server.Listener.Addr().String(),
+	}
+
+	want := []byte("plugin binary contents")
+	if _, err := registry.Push("repo", want); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(uploaded) != string(want) {
+		t.Fatalf("expected the uploaded body to be %q, got %q", want, uploaded)
+	}
+}
@@ -0,0 +1,55 @@
+package dist
+
+import "github.com/docker/infrakit/pkg/spi/flavor"
+
+// manifestIdentity carries a plugin's Manifest and the digest computed over it.  It's embedded by
+// a per-SPI wrapper (e.g. PluginServer) alongside the plugin itself, so the wrapper's Manifest and
+// Digest methods have a single implementation no matter which SPI it forwards.
+type manifestIdentity struct {
+	manifest Manifest
+	digest   Digest
+}
+
+func newManifestIdentity(manifest Manifest) (manifestIdentity, error) {
+	digest, err := Sum(manifest)
+	if err != nil {
+		return manifestIdentity{}, err
+	}
+	return manifestIdentity{manifest: manifest, digest: digest}, nil
+}
+
+// Manifest returns the manifest this plugin was registered with.  RPC services surface this as a
+// `Manifest` method (see rpc/flavor.Flavor.Manifest) so a caller can discover a plugin's digest
+// without reaching into the blobstore directly.
+func (m manifestIdentity) Manifest() (Manifest, error) {
+	return m.manifest, nil
+}
+
+// Digest returns the content digest of this plugin's manifest.
+func (m manifestIdentity) Digest() Digest {
+	return m.digest
+}
+
+// PluginServer wraps a flavor.Plugin with a stable, content-addressable identity: the Manifest it
+// was installed from, keyed by the digest computed over that manifest.  Embedding flavor.Plugin
+// means a *PluginServer is itself a flavor.Plugin, so it can be passed directly to
+// rpc/flavor.PluginServer and exposes Manifest alongside the wrapped plugin's own RPC methods.
+//
+// Instance and Group plugins don't get an equivalent wrapper yet -- this tree has no
+// pkg/spi/instance or pkg/spi/group SPI, nor the rpc/instance or rpc/group services that would
+// need to forward Manifest the way rpc/flavor.Flavor.Manifest does for this one. Once those SPIs
+// and RPC services exist, an InstancePluginServer/GroupPluginServer can embed manifestIdentity the
+// same way this type does.
+type PluginServer struct {
+	flavor.Plugin
+	manifestIdentity
+}
+
+// NewPluginServer wraps plugin with manifest, computing the manifest's content digest.
+func NewPluginServer(plugin flavor.Plugin, manifest Manifest) (*PluginServer, error) {
+	identity, err := newManifestIdentity(manifest)
+	if err != nil {
+		return nil, err
+	}
+	return &PluginServer{Plugin: plugin, manifestIdentity: identity}, nil
+}
@@ -0,0 +1,83 @@
+package privilege
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Kind identifies the category of a single privilege a plugin requires in order to operate.
+type Kind string
+
+const (
+	// Network grants a networking mode not available to an unprivileged plugin, e.g. "host".
+	Network Kind = "network"
+	// Mount grants access to a host path, e.g. "/var/run/docker.sock".
+	Mount Kind = "mount"
+	// Capability grants a Linux capability, e.g. "NET_ADMIN".
+	Capability Kind = "capabilities"
+	// Exec grants permission to exec a named external binary, e.g. "vagrant".
+	Exec Kind = "exec"
+	// Env grants visibility into a host environment variable, or a glob of them, e.g. "AWS_*".
+	Env Kind = "env"
+)
+
+// Privilege is a single capability a plugin declares it needs.
+type Privilege struct {
+	Kind  Kind   `json:"kind"`
+	Value string `json:"value"`
+}
+
+func (p Privilege) String() string {
+	return fmt.Sprintf("%s:%s", p.Kind, p.Value)
+}
+
+// Set is an unordered collection of privileges, as declared by a plugin or granted by a spec.
+type Set []Privilege
+
+// Declarer is implemented by any plugin (flavor, instance, or group) that requires privileges
+// beyond what an unprivileged plugin gets by default, given its current configuration.  The RPC
+// layer surfaces this as a `Privileges` method, taking the same Properties as Validate/Prepare,
+// so `infrakit group commit` can diff declared vs. granted privileges before calling Prepare.
+type Declarer interface {
+	Privileges(properties json.RawMessage) (Set, error)
+}
+
+// Contains reports whether s already contains p.
+func (s Set) Contains(p Privilege) bool {
+	for _, have := range s {
+		if have == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Union returns the privileges present in s or other, without duplicates.
+func (s Set) Union(other Set) Set {
+	result := append(Set{}, s...)
+	for _, p := range other {
+		if !result.Contains(p) {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// Missing returns the privileges in s that are not present in granted -- what still needs to be
+// explicitly granted in a spec before a plugin declaring s can be dispatched.
+func (s Set) Missing(granted Set) Set {
+	missing := Set{}
+	for _, p := range s {
+		if !granted.Contains(p) {
+			missing = append(missing, p)
+		}
+	}
+	return missing
+}
+
+// Grant is the set of privileges an operator has explicitly authorized for a plugin, recorded in
+// a group or flavor spec.  infrakit refuses to call Prepare on a plugin whose declared privileges
+// aren't a subset of its Grant -- `infrakit group commit` shows the diff before committing.
+type Grant struct {
+	Privileges Set `json:",omitempty"`
+}
@@ -0,0 +1,45 @@
+package privilege
+
+import "testing"
+
+func TestSetContains(t *testing.T) {
+	s := Set{{Kind: Exec, Value: "vagrant"}}
+
+	if !s.Contains(Privilege{Kind: Exec, Value: "vagrant"}) {
+		t.Fatal("expected the set to contain the privilege it was built with")
+	}
+	if s.Contains(Privilege{Kind: Exec, Value: "ssh"}) {
+		t.Fatal("expected the set not to contain a privilege it wasn't built with")
+	}
+}
+
+func TestSetUnionDeduplicates(t *testing.T) {
+	a := Set{{Kind: Exec, Value: "vagrant"}, {Kind: Mount, Value: "/tmp"}}
+	b := Set{{Kind: Mount, Value: "/tmp"}, {Kind: Exec, Value: "ssh"}}
+
+	union := a.Union(b)
+
+	if len(union) != 3 {
+		t.Fatalf("expected 3 deduplicated privileges, got %d: %v", len(union), union)
+	}
+	for _, p := range []Privilege{
+		{Kind: Exec, Value: "vagrant"},
+		{Kind: Mount, Value: "/tmp"},
+		{Kind: Exec, Value: "ssh"},
+	} {
+		if !union.Contains(p) {
+			t.Fatalf("expected union to contain %v", p)
+		}
+	}
+}
+
+func TestSetMissing(t *testing.T) {
+	declared := Set{{Kind: Exec, Value: "vagrant"}, {Kind: Exec, Value: "ssh"}}
+	granted := Set{{Kind: Exec, Value: "vagrant"}}
+
+	missing := declared.Missing(granted)
+
+	if len(missing) != 1 || missing[0] != (Privilege{Kind: Exec, Value: "ssh"}) {
+		t.Fatalf("expected only ssh to be missing, got %v", missing)
+	}
+}
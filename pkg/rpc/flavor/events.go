@@ -0,0 +1,276 @@
+package flavor
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/docker/infrakit/pkg/spi/flavor"
+	"github.com/docker/infrakit/pkg/spi/instance"
+)
+
+// Event kinds emitted by the Flavor RPC server.  Clients subscribe by kind via EventFilter.
+const (
+	EventFlavorValidated      = "flavor.Validated"
+	EventFlavorHealthChanged  = "flavor.HealthChanged"
+	EventFlavorDrainStarted   = "flavor.DrainStarted"
+	EventFlavorDrainCompleted = "flavor.DrainCompleted"
+	EventFlavorAttached       = "flavor.Attached"
+	EventFlavorDetached       = "flavor.Detached"
+)
+
+// FlavorValidated is emitted after Validate succeeds for a given flavor type.
+type FlavorValidated struct {
+	Type string
+}
+
+// FlavorHealthChanged is emitted on the first successful Healthy call for an instance, and
+// afterwards whenever Healthy returns a different value than it did on the previous call for the
+// same instance.  On the first call From is the zero Health.
+type FlavorHealthChanged struct {
+	Type       string
+	InstanceID instance.ID
+	From       flavor.Health
+	To         flavor.Health
+}
+
+// FlavorDrainStarted is emitted before Drain is dispatched to the underlying plugin.
+type FlavorDrainStarted struct {
+	Type       string
+	InstanceID instance.ID
+}
+
+// FlavorDrainCompleted is emitted after Drain returns, regardless of outcome.
+type FlavorDrainCompleted struct {
+	Type       string
+	InstanceID instance.ID
+}
+
+// FlavorAttached is emitted when a typed sub-plugin is registered via Attach.
+type FlavorAttached struct {
+	Type string
+}
+
+// FlavorDetached is emitted when a typed sub-plugin is removed via Detach.
+type FlavorDetached struct {
+	Type string
+}
+
+// Event is the wire envelope for a single event published by the plugin.  It is encoded as
+// newline-delimited JSON so that non-Go clients can tail the stream without a generated client.
+type Event struct {
+	Timestamp time.Time       `json:"timestamp"`
+	Kind      string          `json:"kind"`
+	Plugin    string          `json:"plugin"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// EventFilter narrows a subscription down to a set of event kinds.  An empty filter matches
+// every event.
+type EventFilter struct {
+	Kinds []string
+}
+
+func (f EventFilter) matches(kind string) bool {
+	if len(f.Kinds) == 0 {
+		return true
+	}
+	for _, k := range f.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// CancelFunc stops a subscription and releases the resources backing it.
+type CancelFunc func()
+
+// eventBufferSize bounds how many events are queued per subscriber before the oldest are dropped.
+const eventBufferSize = 256
+
+// subscription is a single consumer's view into the event stream.
+type subscription struct {
+	filter EventFilter
+	events chan Event
+}
+
+// eventPublisher fans out events published in-process to every subscription whose filter matches.
+// Each subscription owns a bounded ring buffer so one slow reader can't block another.
+type eventPublisher struct {
+	lock sync.Mutex
+	subs map[*subscription]struct{}
+}
+
+func newEventPublisher() *eventPublisher {
+	return &eventPublisher{subs: map[*subscription]struct{}{}}
+}
+
+func (p *eventPublisher) subscribe(filter EventFilter) (*subscription, CancelFunc) {
+	sub := &subscription{filter: filter, events: make(chan Event, eventBufferSize)}
+
+	p.lock.Lock()
+	p.subs[sub] = struct{}{}
+	p.lock.Unlock()
+
+	return sub, func() {
+		p.lock.Lock()
+		if _, has := p.subs[sub]; has {
+			delete(p.subs, sub)
+			close(sub.events)
+		}
+		p.lock.Unlock()
+	}
+}
+
+func (p *eventPublisher) publish(evt Event) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for sub := range p.subs {
+		if !sub.filter.matches(evt.Kind) {
+			continue
+		}
+		select {
+		case sub.events <- evt:
+		default:
+			// Ring buffer full: drop the oldest event to make room rather than block the
+			// publisher on a slow consumer.
+			select {
+			case <-sub.events:
+			default:
+			}
+			select {
+			case sub.events <- evt:
+			default:
+			}
+		}
+	}
+}
+
+// events lazily initializes and returns the plugin's publisher.
+func (p *Flavor) events() *eventPublisher {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.publisher == nil {
+		p.publisher = newEventPublisher()
+	}
+	return p.publisher
+}
+
+// publish marshals payload and fans it out to subscribers as an Event of the given kind.
+func (p *Flavor) publish(kind, pluginType string, payload interface{}) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	p.events().publish(Event{
+		Timestamp: time.Now(),
+		Kind:      kind,
+		Plugin:    pluginType,
+		Payload:   raw,
+	})
+}
+
+// healthKey identifies an (instance, flavor type) pair for health transition tracking.
+func healthKey(flavorType string, id instance.ID) string {
+	return fmt.Sprintf("%s/%s", flavorType, id)
+}
+
+// recordHealth compares health against the last known value for this instance and, if it
+// changed or none was recorded yet, publishes a FlavorHealthChanged event.
+func (p *Flavor) recordHealth(flavorType string, id instance.ID, health flavor.Health) {
+	key := healthKey(flavorType, id)
+
+	p.lock.Lock()
+	if p.lastHealth == nil {
+		p.lastHealth = map[string]flavor.Health{}
+	}
+	prev, seen := p.lastHealth[key]
+	p.lastHealth[key] = health
+	p.lock.Unlock()
+
+	if seen && prev == health {
+		return
+	}
+
+	p.publish(EventFlavorHealthChanged, flavorType, FlavorHealthChanged{
+		Type:       flavorType,
+		InstanceID: id,
+		From:       prev,
+		To:         health,
+	})
+}
+
+// Subscribe returns a channel of Events matching filter and a CancelFunc to stop the subscription
+// and release its buffer.
+func (p *Flavor) Subscribe(filter EventFilter) (<-chan Event, CancelFunc, error) {
+	sub, cancel := p.events().subscribe(filter)
+	return sub.events, cancel, nil
+}
+
+// ServeEvents streams newline-delimited JSON Event envelopes to the client until the request
+// context is cancelled.  It's the HTTP counterpart of Subscribe for clients not speaking Go
+// net/rpc, e.g. a plain `curl`.
+func (p *Flavor) ServeEvents(w http.ResponseWriter, req *http.Request) {
+	var filter EventFilter
+	if kinds := req.URL.Query()["kind"]; len(kinds) > 0 {
+		filter.Kinds = kinds
+	}
+
+	events, cancel, err := p.Subscribe(filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer cancel()
+
+	flusher, canFlush := w.(http.Flusher)
+	writer := bufio.NewWriter(w)
+	enc := json.NewEncoder(writer)
+
+	for {
+		select {
+		case evt, open := <-events:
+			if !open {
+				return
+			}
+			if err := enc.Encode(evt); err != nil {
+				return
+			}
+			writer.Flush()
+			if canFlush {
+				flusher.Flush()
+			}
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
+
+// Attach registers a typed sub-plugin under flavorType, making it reachable via requests that set
+// Type to flavorType, and emits a FlavorAttached event.
+func (p *Flavor) Attach(flavorType string, plugin flavor.Plugin) error {
+	p.lock.Lock()
+	if p.typedPlugins == nil {
+		p.typedPlugins = map[string]flavor.Plugin{}
+	}
+	p.typedPlugins[flavorType] = plugin
+	p.lock.Unlock()
+
+	p.publish(EventFlavorAttached, flavorType, FlavorAttached{Type: flavorType})
+	return nil
+}
+
+// Detach removes a previously attached typed sub-plugin and emits a FlavorDetached event.
+func (p *Flavor) Detach(flavorType string) error {
+	p.lock.Lock()
+	delete(p.typedPlugins, flavorType)
+	p.lock.Unlock()
+
+	p.publish(EventFlavorDetached, flavorType, FlavorDetached{Type: flavorType})
+	return nil
+}
@@ -0,0 +1,76 @@
+package flavor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/infrakit/pkg/spi/flavor"
+	"github.com/docker/infrakit/pkg/spi/instance"
+)
+
+func TestEventPublisherFilterAndCancel(t *testing.T) {
+	pub := newEventPublisher()
+
+	matching, cancel := pub.subscribe(EventFilter{Kinds: []string{EventFlavorAttached}})
+	defer cancel()
+
+	other, cancelOther := pub.subscribe(EventFilter{Kinds: []string{EventFlavorDetached}})
+	defer cancelOther()
+
+	pub.publish(Event{Kind: EventFlavorAttached})
+
+	select {
+	case evt := <-matching.events:
+		if evt.Kind != EventFlavorAttached {
+			t.Fatalf("expected %s, got %s", EventFlavorAttached, evt.Kind)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the matching subscription to receive the event")
+	}
+
+	select {
+	case evt, open := <-other.events:
+		t.Fatalf("expected no event for a non-matching filter, got %v (open=%v)", evt, open)
+	default:
+	}
+
+	cancel()
+	if _, open := <-matching.events; open {
+		t.Fatal("expected the channel to be closed after cancel")
+	}
+}
+
+func TestRecordHealthPublishesOnlyOnTransition(t *testing.T) {
+	p := &Flavor{}
+
+	events, cancel, err := p.Subscribe(EventFilter{Kinds: []string{EventFlavorHealthChanged}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+
+	id := instance.ID("i-1")
+	p.recordHealth("vanilla", id, flavor.Healthy)
+
+	select {
+	case <-events:
+	case <-time.After(time.Second):
+		t.Fatal("expected a FlavorHealthChanged event for the first observation")
+	}
+
+	// Same health again: no further event.
+	p.recordHealth("vanilla", id, flavor.Healthy)
+	select {
+	case evt := <-events:
+		t.Fatalf("expected no event for an unchanged health, got %v", evt)
+	default:
+	}
+
+	// Health changes: a second event.
+	p.recordHealth("vanilla", id, flavor.Unhealthy)
+	select {
+	case <-events:
+	case <-time.After(time.Second):
+		t.Fatal("expected a FlavorHealthChanged event when health transitions")
+	}
+}
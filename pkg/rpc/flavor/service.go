@@ -4,11 +4,19 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sync"
 
+	"github.com/docker/infrakit/pkg/plugin/dist"
+	"github.com/docker/infrakit/pkg/plugin/privilege"
 	"github.com/docker/infrakit/pkg/spi"
 	"github.com/docker/infrakit/pkg/spi/flavor"
 )
 
+// manifestProvider is implemented by a plugin registered via dist.PluginServer.
+type manifestProvider interface {
+	Manifest() (dist.Manifest, error)
+}
+
 // PluginServer returns a Flavor that conforms to the net/rpc rpc call convention.
 func PluginServer(p flavor.Plugin) *Flavor {
 	return &Flavor{plugin: p}
@@ -24,6 +32,10 @@ func PluginServerWithTypes(typed map[string]flavor.Plugin) *Flavor {
 type Flavor struct {
 	plugin       flavor.Plugin
 	typedPlugins map[string]flavor.Plugin // by type, as qualified in the name of the plugin
+
+	lock       sync.Mutex
+	publisher  *eventPublisher
+	lastHealth map[string]flavor.Health // by healthKey(type, instance), for change detection
 }
 
 // VendorInfo returns a metadata object about the plugin, if the plugin implements it.  See spi.Vendor
@@ -39,6 +51,27 @@ func (p *Flavor) VendorInfo() *spi.VendorInfo {
 	return nil
 }
 
+// Manifest returns the content-addressable manifest for this plugin, if it was registered via
+// dist.PluginServer, so callers can discover the digest that pins this plugin (e.g.
+// "vanilla@sha256:...") without reaching into the blobstore directly.
+func (p *Flavor) Manifest() (*dist.Manifest, error) {
+	// TODO(chungers) - support typed plugins
+	if p.plugin == nil {
+		return nil, nil
+	}
+
+	m, is := p.plugin.(manifestProvider)
+	if !is {
+		return nil, nil
+	}
+
+	manifest, err := m.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
 // SetExampleProperties sets the rpc request with any example properties/ custom type
 func (p *Flavor) SetExampleProperties(request interface{}) {
 	// TODO(chungers) - support typed plugins
@@ -82,12 +115,56 @@ func (p *Flavor) getPlugin(flavorType string) flavor.Plugin {
 	if flavorType == "" {
 		return p.plugin
 	}
-	if p, has := p.typedPlugins[flavorType]; has {
-		return p
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if found, has := p.typedPlugins[flavorType]; has {
+		return found
 	}
 	return nil
 }
 
+// PrivilegesRequest carries the Properties to compute declared privileges for.  Mirrors
+// ValidateRequest's shape since the privileges a plugin needs are a function of how it's
+// configured, not just which plugin it is.
+type PrivilegesRequest struct {
+	Type       string
+	Properties *json.RawMessage
+}
+
+// PrivilegesResponse carries the privileges declared for the requested configuration.
+type PrivilegesResponse struct {
+	Type       string
+	Privileges privilege.Set
+}
+
+// Privileges returns the capabilities the plugin declares it needs for the given configuration,
+// if it implements privilege.Declarer.  An empty result means the plugin has no elevated
+// requirements. See pkg/plugin/privilege.
+func (p *Flavor) Privileges(_ *http.Request, req *PrivilegesRequest, resp *PrivilegesResponse) error {
+	var raw json.RawMessage
+	if req.Properties != nil {
+		raw = *req.Properties
+	}
+
+	resp.Type = req.Type
+	c := p.getPlugin(req.Type)
+	if c == nil {
+		return fmt.Errorf("no-plugin:%s", req.Type)
+	}
+
+	d, is := c.(privilege.Declarer)
+	if !is {
+		return nil
+	}
+
+	declared, err := d.Privileges(raw)
+	if err != nil {
+		return err
+	}
+	resp.Privileges = declared
+	return nil
+}
+
 // Validate checks whether the helper can support a configuration.
 func (p *Flavor) Validate(_ *http.Request, req *ValidateRequest, resp *ValidateResponse) error {
 	var raw json.RawMessage
@@ -105,6 +182,7 @@ func (p *Flavor) Validate(_ *http.Request, req *ValidateRequest, resp *ValidateR
 		return err
 	}
 	resp.OK = true
+	p.publish(EventFlavorValidated, req.Type, FlavorValidated{Type: req.Type})
 	return nil
 }
 
@@ -142,6 +220,7 @@ func (p *Flavor) Healthy(_ *http.Request, req *HealthyRequest, resp *HealthyResp
 		return err
 	}
 	resp.Health = health
+	p.recordHealth(req.Type, req.Instance.ID, health)
 	return nil
 }
 
@@ -152,6 +231,10 @@ func (p *Flavor) Drain(_ *http.Request, req *DrainRequest, resp *DrainResponse)
 	if c == nil {
 		return fmt.Errorf("no-plugin:%s", req.Type)
 	}
+
+	p.publish(EventFlavorDrainStarted, req.Type, FlavorDrainStarted{Type: req.Type, InstanceID: req.Instance.ID})
+	defer p.publish(EventFlavorDrainCompleted, req.Type, FlavorDrainCompleted{Type: req.Type, InstanceID: req.Instance.ID})
+
 	err := c.Drain(*req.Properties, req.Instance)
 	if err != nil {
 		return err
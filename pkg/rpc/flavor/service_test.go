@@ -0,0 +1,64 @@
+package flavor
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/docker/infrakit/pkg/plugin/dist"
+	"github.com/docker/infrakit/pkg/plugin/group/types"
+	"github.com/docker/infrakit/pkg/spi/flavor"
+	"github.com/docker/infrakit/pkg/spi/instance"
+)
+
+type fakeFlavorPlugin struct{}
+
+func (fakeFlavorPlugin) Validate(flavorProperties json.RawMessage, allocation types.AllocationMethod) error {
+	return nil
+}
+
+func (fakeFlavorPlugin) Prepare(flavorProperties json.RawMessage, inst instance.Spec, allocation types.AllocationMethod) (instance.Spec, error) {
+	return inst, nil
+}
+
+func (fakeFlavorPlugin) Healthy(flavorProperties json.RawMessage, inst instance.Description) (flavor.Health, error) {
+	return flavor.Healthy, nil
+}
+
+func (fakeFlavorPlugin) Drain(flavorProperties json.RawMessage, inst instance.Description) error {
+	return nil
+}
+
+func TestManifestRoundTripsThroughPluginServer(t *testing.T) {
+	manifest := dist.Manifest{SchemaVersion: dist.SchemaVersion, Entrypoint: "vanilla"}
+
+	wrapped, err := dist.NewPluginServer(fakeFlavorPlugin{}, manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := PluginServer(wrapped)
+
+	got, err := server.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil {
+		t.Fatal("expected a non-nil manifest for a plugin wrapped by dist.PluginServer")
+	}
+	if got.Entrypoint != manifest.Entrypoint {
+		t.Fatalf("expected entrypoint %q, got %q", manifest.Entrypoint, got.Entrypoint)
+	}
+
+	wantDigest, err := dist.Sum(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wrapped.Digest() != wantDigest {
+		t.Fatalf("expected digest %s, got %s", wantDigest, wrapped.Digest())
+	}
+
+	// The wrapped plugin's own RPC methods must still be reachable through the server.
+	if _, err := server.plugin.Prepare(nil, instance.Spec{}, types.AllocationMethod{}); err != nil {
+		t.Fatalf("expected the wrapped plugin's Prepare to be forwarded: %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package template
+
+import "testing"
+
+func TestParseContentRef(t *testing.T) {
+	ref, ok := parseContentRef("oci://registry.example.com/vanilla@sha256:abc")
+	if !ok || ref.scheme != "oci" || ref.repository != "registry.example.com/vanilla" || ref.digest != "sha256:abc" {
+		t.Fatalf("unexpected parse of oci ref: %+v %v", ref, ok)
+	}
+
+	ref, ok = parseContentRef("ipfs://QmFoo")
+	if !ok || ref.scheme != "ipfs" || ref.cid != "QmFoo" {
+		t.Fatalf("unexpected parse of ipfs ref: %+v %v", ref, ok)
+	}
+
+	if _, ok := parseContentRef("relative/path.tpl"); ok {
+		t.Fatal("expected a plain relative path not to be treated as a content ref")
+	}
+}
+
+func TestVerifyCIDDetectsMismatch(t *testing.T) {
+	content := []byte("hello ipfs content")
+	cid := "QmYHfK1SXRMF28XeVJgdZGcevyYCHd8AqDQHr1ovp21iFt"
+
+	if err := verifyCID(cid, content); err != nil {
+		t.Fatalf("expected a matching cid to verify, got %v", err)
+	}
+	if err := verifyCID(cid, []byte("tampered content")); err == nil {
+		t.Fatal("expected a mismatched cid to fail verification")
+	}
+}
+
+func TestVerifyContentChecksBothSchemes(t *testing.T) {
+	body := []byte("manifest-bytes")
+	digest := sumBytes(body)
+
+	ociRef := contentRef{scheme: "oci", repository: "registry.example.com/vanilla", digest: digest}
+	if err := verifyContent(ociRef, "", body); err != nil {
+		t.Fatalf("expected a matching oci digest to verify: %v", err)
+	}
+	if err := verifyContent(ociRef, "", []byte("tampered")); err == nil {
+		t.Fatal("expected an oci digest mismatch to be rejected")
+	}
+
+	ipfsBody := []byte("hello ipfs content")
+	ipfsRef := contentRef{scheme: "ipfs", cid: "QmYHfK1SXRMF28XeVJgdZGcevyYCHd8AqDQHr1ovp21iFt"}
+	if err := verifyContent(ipfsRef, "", ipfsBody); err != nil {
+		t.Fatalf("expected a matching ipfs cid to verify: %v", err)
+	}
+	if err := verifyContent(ipfsRef, "", []byte("tampered")); err == nil {
+		t.Fatal("expected an ipfs cid mismatch to be rejected")
+	}
+
+	// A suffixed fetch (e.g. a detached ".sig") has no digest of its own to check here.
+	if err := verifyContent(ociRef, ".sig", []byte("anything")); err != nil {
+		t.Fatalf("expected a suffixed fetch to be left unverified here, got %v", err)
+	}
+}
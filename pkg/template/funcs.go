@@ -83,6 +83,18 @@ func (t *Template) DefaultFuncs() map[string]interface{} {
 			if len(opt) > 0 {
 				o = opt[0]
 			}
+
+			// oci:// and ipfs:// refs are content-addressed: fetch, verify against their
+			// digest, and cache, rather than resolved relative to t.url.
+			if ref, isContentRef := parseContentRef(p); isContentRef {
+				return t.renderContentRef(ref, o)
+			}
+
+			if t.options.RequireSigned {
+				return "", fmt.Errorf("include %q: RequireSigned is set; use a digest-pinned "+
+					"oci:// or ipfs:// ref instead", p)
+			}
+
 			loc, err := getURL(t.url, p)
 			if err != nil {
 				return "", err
@@ -102,6 +114,30 @@ func (t *Template) DefaultFuncs() map[string]interface{} {
 			return included.Render(o)
 		},
 
+		// include_digest is like include, but only accepts a content-addressed oci:// or
+		// ipfs:// ref -- useful when a template wants to assert, at the call site, that a
+		// fragment it depends on is pinned rather than resolved relative to t.url.
+		"include_digest": func(p string, opt ...interface{}) (string, error) {
+			ref, isContentRef := parseContentRef(p)
+			if !isContentRef {
+				return "", fmt.Errorf("include_digest %q: not an oci:// or ipfs:// ref", p)
+			}
+			var o interface{}
+			if len(opt) > 0 {
+				o = opt[0]
+			}
+			return t.renderContentRef(ref, o)
+		},
+
+		// verify_signature checks a detached signature over content against the template's
+		// configured Keyring (see Template.Options), succeeding if any key verifies it.
+		"verify_signature": func(content, sig string) (bool, error) {
+			if err := verifySignature([]byte(content), []byte(sig), t.options.Keyring); err != nil {
+				return false, err
+			}
+			return true, nil
+		},
+
 		"var": func(name, doc string, v ...interface{}) interface{} {
 			if found, has := t.binds[name]; has {
 				return found
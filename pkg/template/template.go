@@ -0,0 +1,117 @@
+package template
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	gotemplate "text/template"
+)
+
+// Options controls how a Template resolves includes and verifies signed content.
+type Options struct {
+	// RequireSigned refuses any include that isn't a digest-pinned oci:// or ipfs:// ref, instead of
+	// one resolved relative to the including template's url.  It does not by itself require a
+	// signature -- pair it with a verify_signature call in the template to also mandate one.
+	RequireSigned bool
+
+	// Keyring is the set of public keys trusted to verify signed template fragments.
+	Keyring []PublicKey
+}
+
+// Template represents a parsed document, with a set of bound variables and functions that includes
+// inherit from their parent.
+type Template struct {
+	url     string
+	options Options
+	body    []byte
+	binds   map[string]interface{}
+	funcs   map[string]interface{}
+}
+
+// NewTemplate fetches the document at loc (a relative path, or a file:// or http(s):// URL) and
+// returns a Template ready to be rendered.
+func NewTemplate(loc string, opt Options) (*Template, error) {
+	body, err := fetch(loc)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Template{
+		url:     loc,
+		options: opt,
+		body:    body,
+		binds:   map[string]interface{}{},
+		funcs:   map[string]interface{}{},
+	}
+	for name, fn := range t.DefaultFuncs() {
+		t.AddFunc(name, fn)
+	}
+	return t, nil
+}
+
+// AddFunc registers fn under name for use in the template body.
+func (t *Template) AddFunc(name string, fn interface{}) {
+	t.funcs[name] = fn
+}
+
+// Render executes the template against context and returns the result.
+func (t *Template) Render(context interface{}) (string, error) {
+	var buff bytes.Buffer
+	if err := t.Execute(&buff, context); err != nil {
+		return "", err
+	}
+	return buff.String(), nil
+}
+
+// Execute executes the template against context, writing the result to w.
+func (t *Template) Execute(w io.Writer, context interface{}) error {
+	parsed, err := gotemplate.New(t.url).Funcs(gotemplate.FuncMap(t.funcs)).Parse(string(t.body))
+	if err != nil {
+		return err
+	}
+	return parsed.Execute(w, context)
+}
+
+// getURL resolves p against base: an absolute file:// or http(s):// reference is returned as-is;
+// anything else is resolved relative to base's directory.
+func getURL(base, p string) (string, error) {
+	if strings.Contains(p, "://") {
+		return p, nil
+	}
+	if base == "" || !strings.Contains(base, "://") {
+		return filepath.Join(filepath.Dir(base), p), nil
+	}
+
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	u.Path = path.Join(path.Dir(u.Path), p)
+	return u.String(), nil
+}
+
+// fetch reads the document at loc, dispatching on its scheme; a bare path is read from disk.
+func fetch(loc string) ([]byte, error) {
+	switch {
+	case strings.HasPrefix(loc, "http://"), strings.HasPrefix(loc, "https://"):
+		resp, err := http.Get(loc)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("fetch %s: %s", loc, resp.Status)
+		}
+		return ioutil.ReadAll(resp.Body)
+	case strings.HasPrefix(loc, "file://"):
+		return ioutil.ReadFile(strings.TrimPrefix(loc, "file://"))
+	default:
+		return ioutil.ReadFile(loc)
+	}
+}
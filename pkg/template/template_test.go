@@ -0,0 +1,77 @@
+package template
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewTemplateRenderFromFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "template-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	loc := filepath.Join(dir, "doc.tpl")
+	if err := ioutil.WriteFile(loc, []byte("hello {{.Name}}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl, err := NewTemplate(loc, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := tmpl.Render(map[string]interface{}{"Name": "world"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "hello world" {
+		t.Fatalf("expected %q, got %q", "hello world", out)
+	}
+}
+
+func TestRequireSignedRejectsPlainInclude(t *testing.T) {
+	dir, err := ioutil.TempDir("", "template-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	child := filepath.Join(dir, "child.tpl")
+	if err := ioutil.WriteFile(child, []byte("child"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	parent := filepath.Join(dir, "parent.tpl")
+	if err := ioutil.WriteFile(parent, []byte(`{{ include "child.tpl" }}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl, err := NewTemplate(parent, Options{RequireSigned: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tmpl.Render(nil); err == nil {
+		t.Fatal("expected RequireSigned to reject an include that isn't a digest-pinned ref")
+	}
+}
+
+func TestGetURLResolvesRelativeToBase(t *testing.T) {
+	loc, err := getURL("/a/b/base.tpl", "child.tpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loc != filepath.Join("/a/b", "child.tpl") {
+		t.Fatalf("expected child.tpl resolved against base's directory, got %s", loc)
+	}
+
+	loc, err = getURL("/a/b/base.tpl", "http://example.com/other.tpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loc != "http://example.com/other.tpl" {
+		t.Fatalf("expected an absolute URL to be returned unchanged, got %s", loc)
+	}
+}